@@ -0,0 +1,354 @@
+package docker
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+const dockerExe = "docker"
+
+// proxyKeys are the proxy-related environment variable names that Proxy
+// resolves from the runner environment and forwards into docker builds.
+var proxyKeys = []string{"no_proxy", "http_proxy", "https_proxy", "all_proxy"}
+
+type (
+	// Login defines Docker login parameters.
+	Login struct {
+		Registry string // Docker registry address
+		Username string // Docker registry username
+		Password string // Docker registry password
+		Email    string // Docker registry email
+		Config   string // Docker Auth Config
+	}
+
+	// Build defines Docker build parameters.
+	Build struct {
+		Remote       string   // Git remote URL
+		Name         string   // Docker build using default named tag
+		Dockerfile   string   // Docker build Dockerfile
+		Context      string   // Docker build context
+		Tags         []string // Docker build tags
+		Args         []string // Docker build args
+		ArgsEnv      []string // Docker build args from env
+		Target       string   // Docker build target
+		Squash       bool     // Docker build squash
+		Pull         bool     // Docker build pull
+		CacheFrom    []string // Docker build cache-from
+		Compress     bool     // Docker build compress
+		Repo         string   // Docker build repository
+		LabelSchema  []string // Label schema
+		AutoLabel    bool     // Auto label true
+		Labels       []string // Label schema map
+		Link         string   // Link name
+		NoCache      bool     // Docker build no-cache
+		AddHost      []string // Docker build add-host
+		Quiet        bool     // Docker build quiet
+		Secrets      []string // Docker build secrets
+		SecretEnvs   []string // Docker build secrets with env var as source
+		SecretFiles  []string // Docker build secrets with file as source
+		SSHAgentKey  string   // Docker build ssh agent key
+		SSHKeyPath   string   // Docker build ssh key path
+		Platform     string   // Docker build platform
+		TempTag      string   // Temporary tag used for the build
+		Mirror       string   // Docker registry mirror
+		NoProxyBuild bool     // Disables automatic proxy build-arg injection
+	}
+
+	// Daemon defines Docker daemon parameters.
+	Daemon struct {
+		Registry      string            // Docker registry
+		Mirror        string            // Docker registry mirror
+		Insecure      bool              // Docker daemon enable insecure registries
+		StorageDriver string            // Docker daemon storage driver
+		StoragePath   string            // Docker daemon storage path
+		Disabled      bool              // DOcker daemon is disabled
+		Debug         bool              // Docker daemon started in debug mode
+		Bip           string            // Docker daemon network bridge IP address
+		DNS           []string          // Docker daemon dns server
+		DNSSearch     []string          // Docker daemon dns search domain
+		MTU           string            // Docker daemon mtu setting
+		IPv6          bool              // Docker daemon IPv6 networking
+		Experimental  bool              // Docker daemon enable experimental mode
+		Proxy         map[string]string // Proxy env vars, see NewDaemon and (Daemon).WriteConfig
+	}
+
+	// Plugin defines the Docker plugin parameters.
+	Plugin struct {
+		Login   Login   // Docker login configuration
+		Logins  []Login // Additional registry credentials, e.g. from PLUGIN_LOGINS
+		Build   Build   // Docker build configuration
+		Daemon  Daemon  // Docker daemon configuration
+		Dryrun  bool    // Docker push is skipped
+		Cleanup bool    // Docker purge is enabled
+	}
+)
+
+// commandBuild returns the docker command for Build.
+func commandBuild(build Build) *exec.Cmd {
+	args := []string{
+		"build",
+		"--rm=true",
+		"-f", build.Dockerfile,
+		"-t", build.TempTag,
+		build.Context,
+	}
+
+	args = append(args, buildArgFlags(build)...)
+	args = append(args, cacheFromFlags(build)...)
+
+	if build.Platform != "" {
+		args = append(args, "--platform", build.Platform)
+	}
+
+	if build.SSHKeyPath != "" {
+		args = append(args, fmt.Sprintf("--ssh %s", build.SSHKeyPath))
+	}
+
+	for _, secret := range build.SecretEnvs {
+		if parts := strings.SplitN(secret, "=", 2); len(parts) == 2 && parts[0] != "" && parts[1] != "" {
+			args = append(args, fmt.Sprintf("--secret id=%s,env=%s", parts[0], parts[1]))
+		}
+	}
+
+	for _, secret := range build.SecretFiles {
+		if parts := strings.SplitN(secret, "=", 2); len(parts) == 2 && parts[0] != "" && parts[1] != "" {
+			args = append(args, fmt.Sprintf("--secret id=%s,src=%s", parts[0], parts[1]))
+		}
+	}
+
+	return exec.Command(dockerExe, args...)
+}
+
+// buildArgFlags resolves the full set of --build-arg flags for build,
+// merging auto-discovered proxy settings underneath the user-supplied
+// BuildArgs/BuildArgsFromEnv values so proxy values never clobber them.
+// Setting either case of a proxy key (e.g. HTTP_PROXY or http_proxy)
+// suppresses both auto-injected forms of that key, so the user's value
+// isn't shadowed by its other-case counterpart.
+func buildArgFlags(build Build) []string {
+	buildArgs := map[string]string{}
+
+	if !build.NoProxyBuild {
+		for key, value := range Proxy() {
+			buildArgs[key] = value
+		}
+	}
+
+	setUserArg := func(key, value string) {
+		if isProxyKey(key) {
+			lower := strings.ToLower(key)
+			delete(buildArgs, lower)
+			delete(buildArgs, strings.ToUpper(lower))
+		}
+		buildArgs[key] = value
+	}
+
+	for _, kv := range build.ArgsEnv {
+		key, envKey := kv, kv
+		if idx := strings.Index(kv, "="); idx != -1 {
+			key, envKey = kv[:idx], kv[idx+1:]
+		}
+		if value, ok := os.LookupEnv(envKey); ok {
+			setUserArg(key, value)
+		}
+	}
+
+	for _, kv := range build.Args {
+		if idx := strings.Index(kv, "="); idx != -1 {
+			setUserArg(kv[:idx], kv[idx+1:])
+		}
+	}
+
+	keys := make([]string, 0, len(buildArgs))
+	for key := range buildArgs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	args := make([]string, 0, len(keys)*2)
+	for _, key := range keys {
+		args = append(args, "--build-arg", fmt.Sprintf("%s=%s", key, buildArgs[key]))
+	}
+	return args
+}
+
+// Proxy resolves the http_proxy, https_proxy, no_proxy, and all_proxy
+// settings from the runner environment via getProxyValue and returns them
+// keyed under both their lowercase and uppercase names, so that downstream
+// tools which only read one case still see the value.
+func Proxy() map[string]string {
+	proxy := map[string]string{}
+
+	for _, key := range proxyKeys {
+		value := getProxyValue(key)
+		if value == "" {
+			continue
+		}
+		proxy[key] = value
+		proxy[strings.ToUpper(key)] = value
+	}
+
+	return proxy
+}
+
+// isProxyKey reports whether key names one of the proxy settings Proxy
+// resolves, regardless of case.
+func isProxyKey(key string) bool {
+	lower := strings.ToLower(key)
+	for _, proxyKey := range proxyKeys {
+		if proxyKey == lower {
+			return true
+		}
+	}
+	return false
+}
+
+// cacheFromFlags resolves build.CacheFrom into --cache-from flags, rewriting
+// each entry to pull through build.Mirror when one is configured. Entries
+// that aren't valid image references are skipped.
+func cacheFromFlags(build Build) []string {
+	args := make([]string, 0, len(build.CacheFrom)*2)
+
+	for _, cacheFrom := range build.CacheFrom {
+		ref, err := ParseReference(cacheFrom)
+		if err != nil {
+			continue
+		}
+		if build.Mirror != "" {
+			ref = ref.WithRegistry(build.Mirror)
+		}
+		args = append(args, "--cache-from", ref.String())
+	}
+
+	return args
+}
+
+// commandTag returns the docker command for tagging an image, resolving
+// the target repository through Reference, the same type commandBuild and
+// commandPush build their image arguments from. It returns an error when
+// build.Repo is not a valid reference or tag is not a valid tag.
+func commandTag(build Build, tag string) (*exec.Cmd, error) {
+	repo, err := ParseReference(build.Repo)
+	if err != nil {
+		return nil, err
+	}
+	if !tagPattern.MatchString(tag) {
+		return nil, fmt.Errorf("docker: invalid tag %q", tag)
+	}
+
+	target := repo.WithTag(tag).String()
+	return exec.Command(dockerExe, "tag", build.Name, target), nil
+}
+
+// commandPush returns the docker command for pushing the image identified
+// by ref.
+func commandPush(ref Reference) *exec.Cmd {
+	return exec.Command(dockerExe, "push", ref.String())
+}
+
+// commandLogin returns the docker command for authenticating to a registry.
+func commandLogin(login Login) *exec.Cmd {
+	if login.Email != "" {
+		return commandLoginEmail(login)
+	}
+	return exec.Command(
+		dockerExe, "login",
+		"-u", login.Username,
+		"-p", login.Password,
+		login.Registry,
+	)
+}
+
+func commandLoginEmail(login Login) *exec.Cmd {
+	return exec.Command(
+		dockerExe, "login",
+		"-u", login.Username,
+		"-p", login.Password,
+		"-e", login.Email,
+		login.Registry,
+	)
+}
+
+// NewDaemon builds a Daemon with Proxy populated from Proxy(), unless
+// noProxy is set, so pull-time proxy settings are ready to be written
+// alongside the daemon's own configuration via (Daemon).WriteConfig.
+func NewDaemon(noProxy bool) Daemon {
+	daemon := Daemon{}
+	if !noProxy {
+		daemon.Proxy = Proxy()
+	}
+	return daemon
+}
+
+// WriteConfig writes d.Proxy into the docker client config file at
+// configPath via WriteDockerConfigProxy.
+func (d Daemon) WriteConfig(configPath string) error {
+	return WriteDockerConfigProxy(configPath, d.Proxy)
+}
+
+// commandDaemon returns the docker daemon command.
+func commandDaemon(daemon Daemon) *exec.Cmd {
+	args := []string{}
+
+	if daemon.Mirror != "" {
+		args = append(args, "--registry-mirror", daemon.Mirror)
+	}
+	if daemon.Insecure && daemon.Registry != "" {
+		args = append(args, "--insecure-registry", daemon.Registry)
+	}
+	if daemon.StorageDriver != "" {
+		args = append(args, "-s", daemon.StorageDriver)
+	}
+	if daemon.StoragePath != "" {
+		args = append(args, "-g", daemon.StoragePath)
+	}
+	if daemon.Bip != "" {
+		args = append(args, "--bip", daemon.Bip)
+	}
+	for _, dns := range daemon.DNS {
+		args = append(args, "--dns", dns)
+	}
+	for _, dnsSearch := range daemon.DNSSearch {
+		args = append(args, "--dns-search", dnsSearch)
+	}
+	if daemon.MTU != "" {
+		args = append(args, "--mtu", daemon.MTU)
+	}
+	if daemon.IPv6 {
+		args = append(args, "--ipv6")
+	}
+	if daemon.Experimental {
+		args = append(args, "--experimental")
+	}
+
+	return exec.Command(dockerExe, args...)
+}
+
+// getProxyValue returns the proxy value for the given key, checking the
+// lowercase, uppercase, and HARNESS_-prefixed uppercase environment
+// variables in that order of precedence.
+func getProxyValue(key string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+
+	upperKey := strings.ToUpper(key)
+	if value := os.Getenv(upperKey); value != "" {
+		return value
+	}
+
+	if value := os.Getenv("HARNESS_" + upperKey); value != "" {
+		return value
+	}
+
+	return ""
+}
+
+// trace writes each command argument to standard error so it can be
+// logged when debugging a build.
+func trace(cmd *exec.Cmd) {
+	fmt.Fprintf(os.Stderr, "+ %s\n", strings.Join(cmd.Args, " "))
+}
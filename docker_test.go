@@ -11,11 +11,21 @@ import (
 )
 
 func TestCommandBuild(t *testing.T) {
+	for _, key := range proxyKeys {
+		os.Unsetenv(key)
+		os.Unsetenv(strings.ToUpper(key))
+		os.Unsetenv("HARNESS_" + strings.ToUpper(key))
+	}
+
+	os.Setenv("BUILD_ARGS_ENV_VAR", "from-env")
+	defer os.Unsetenv("BUILD_ARGS_ENV_VAR")
+
 	tempTag := strings.ToLower(uniuri.New())
 	tcs := []struct {
-		name  string
-		build Build
-		want  *exec.Cmd
+		name    string
+		build   Build
+		envVars map[string]string
+		want    *exec.Cmd
 	}{
 		{
 			name: "secret from env var",
@@ -166,12 +176,113 @@ func TestCommandBuild(t *testing.T) {
 				"--ssh id_rsa=/root/.ssh/id_rsa",
 			),
 		},
+		{
+			name: "build args from literal and env",
+			build: Build{
+				Name:         "plugins/drone-docker:latest",
+				TempTag:      tempTag,
+				Dockerfile:   "Dockerfile",
+				Context:      ".",
+				NoProxyBuild: true,
+				Args:         []string{"VERSION=1.0.0"},
+				ArgsEnv:      []string{"BUILD_ARGS_ENV_VAR"},
+			},
+			want: exec.Command(
+				dockerExe,
+				"build",
+				"--rm=true",
+				"-f",
+				"Dockerfile",
+				"-t",
+				tempTag,
+				".",
+				"--build-arg",
+				"BUILD_ARGS_ENV_VAR=from-env",
+				"--build-arg",
+				"VERSION=1.0.0",
+			),
+		},
+		{
+			name: "proxy values do not overwrite build args",
+			build: Build{
+				Name:       "plugins/drone-docker:latest",
+				TempTag:    tempTag,
+				Dockerfile: "Dockerfile",
+				Context:    ".",
+				Args:       []string{"http_proxy=http://user-proxy:8080"},
+			},
+			envVars: map[string]string{"http_proxy": "http://auto-detected-proxy:8080"},
+			want: exec.Command(
+				dockerExe,
+				"build",
+				"--rm=true",
+				"-f",
+				"Dockerfile",
+				"-t",
+				tempTag,
+				".",
+				"--build-arg",
+				"http_proxy=http://user-proxy:8080",
+			),
+		},
+		{
+			name: "cache-from rewritten through mirror",
+			build: Build{
+				Name:         "plugins/drone-docker:latest",
+				TempTag:      tempTag,
+				Dockerfile:   "Dockerfile",
+				Context:      ".",
+				NoProxyBuild: true,
+				CacheFrom:    []string{"plugins/drone-docker:latest", "not a valid ref!"},
+				Mirror:       "mirror.example.com",
+			},
+			want: exec.Command(
+				dockerExe,
+				"build",
+				"--rm=true",
+				"-f",
+				"Dockerfile",
+				"-t",
+				tempTag,
+				".",
+				"--cache-from",
+				"mirror.example.com/plugins/drone-docker:latest",
+			),
+		},
+		{
+			name: "cache-from with registry and no namespace left untouched without a mirror",
+			build: Build{
+				Name:         "plugins/drone-docker:latest",
+				TempTag:      tempTag,
+				Dockerfile:   "Dockerfile",
+				Context:      ".",
+				NoProxyBuild: true,
+				CacheFrom:    []string{"123456.dkr.ecr.us-east-1.amazonaws.com/myimage:latest"},
+			},
+			want: exec.Command(
+				dockerExe,
+				"build",
+				"--rm=true",
+				"-f",
+				"Dockerfile",
+				"-t",
+				tempTag,
+				".",
+				"--cache-from",
+				"123456.dkr.ecr.us-east-1.amazonaws.com/myimage:latest",
+			),
+		},
 	}
 
 	for _, tc := range tcs {
 		tc := tc
 
 		t.Run(tc.name, func(t *testing.T) {
+			for k, v := range tc.envVars {
+				os.Setenv(k, v)
+				defer os.Unsetenv(k)
+			}
+
 			cmd := commandBuild(tc.build)
 
 			if !reflect.DeepEqual(cmd.String(), tc.want.String()) {
@@ -181,6 +292,90 @@ func TestCommandBuild(t *testing.T) {
 	}
 }
 
+func TestCommandTag(t *testing.T) {
+	tcs := []struct {
+		name    string
+		build   Build
+		tag     string
+		want    *exec.Cmd
+		wantErr bool
+	}{
+		{
+			name: "namespace and name repo",
+			build: Build{
+				Name: "tmp-image",
+				Repo: "plugins/drone-docker",
+			},
+			tag:  "latest",
+			want: exec.Command(dockerExe, "tag", "tmp-image", "docker.io/plugins/drone-docker:latest"),
+		},
+		{
+			name: "registry with single-name repo, no namespace",
+			build: Build{
+				Name: "tmp-image",
+				Repo: "123456.dkr.ecr.us-east-1.amazonaws.com/myimage",
+			},
+			tag:  "v1",
+			want: exec.Command(dockerExe, "tag", "tmp-image", "123456.dkr.ecr.us-east-1.amazonaws.com/myimage:v1"),
+		},
+		{
+			name: "port-qualified registry repo",
+			build: Build{
+				Name: "tmp-image",
+				Repo: "localhost:5000/myimage",
+			},
+			tag:  "v1",
+			want: exec.Command(dockerExe, "tag", "tmp-image", "localhost:5000/myimage:v1"),
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			cmd, err := commandTag(tc.build, tc.tag)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("commandTag() error = nil, want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("commandTag() unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(cmd.String(), tc.want.String()) {
+				t.Errorf("Got cmd %v, want %v", cmd, tc.want)
+			}
+		})
+	}
+}
+
+func TestCommandPush(t *testing.T) {
+	tcs := []struct {
+		name string
+		ref  Reference
+		want *exec.Cmd
+	}{
+		{
+			name: "namespace and name",
+			ref:  Reference{Registry: "docker.io", Namespace: "plugins", Name: "drone-docker", Tag: "latest"},
+			want: exec.Command(dockerExe, "push", "docker.io/plugins/drone-docker:latest"),
+		},
+		{
+			name: "registry with single-name repo, no namespace",
+			ref:  Reference{Registry: "123456.dkr.ecr.us-east-1.amazonaws.com", Name: "myimage", Tag: "v1"},
+			want: exec.Command(dockerExe, "push", "123456.dkr.ecr.us-east-1.amazonaws.com/myimage:v1"),
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			cmd := commandPush(tc.ref)
+			if !reflect.DeepEqual(cmd.String(), tc.want.String()) {
+				t.Errorf("Got cmd %v, want %v", cmd, tc.want)
+			}
+		})
+	}
+}
+
 func TestGetProxyValue(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -267,3 +462,134 @@ func TestGetProxyValue(t *testing.T) {
 		})
 	}
 }
+
+func TestProxy(t *testing.T) {
+	tests := []struct {
+		name     string
+		envVars  map[string]string
+		expected map[string]string
+	}{
+		{
+			name: "lowercase and uppercase both populated",
+			envVars: map[string]string{
+				"http_proxy": "http://proxy:8080",
+			},
+			expected: map[string]string{
+				"http_proxy": "http://proxy:8080",
+				"HTTP_PROXY": "http://proxy:8080",
+			},
+		},
+		{
+			name: "multiple proxy keys resolved independently",
+			envVars: map[string]string{
+				"HTTPS_PROXY": "https://proxy:8443",
+				"no_proxy":    "localhost,127.0.0.1",
+			},
+			expected: map[string]string{
+				"https_proxy": "https://proxy:8443",
+				"HTTPS_PROXY": "https://proxy:8443",
+				"no_proxy":    "localhost,127.0.0.1",
+				"NO_PROXY":    "localhost,127.0.0.1",
+			},
+		},
+		{
+			name:     "no proxy env vars set",
+			envVars:  map[string]string{},
+			expected: map[string]string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range proxyKeys {
+				os.Unsetenv(key)
+				os.Unsetenv(strings.ToUpper(key))
+				os.Unsetenv("HARNESS_" + strings.ToUpper(key))
+			}
+
+			for k, v := range tt.envVars {
+				os.Setenv(k, v)
+				defer os.Unsetenv(k)
+			}
+
+			result := Proxy()
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("Proxy() = %v, want %v", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestNewDaemon(t *testing.T) {
+	for _, key := range proxyKeys {
+		os.Unsetenv(key)
+		os.Unsetenv(strings.ToUpper(key))
+		os.Unsetenv("HARNESS_" + strings.ToUpper(key))
+	}
+
+	os.Setenv("http_proxy", "http://proxy:8080")
+	defer os.Unsetenv("http_proxy")
+
+	daemon := NewDaemon(false)
+	if daemon.Proxy["http_proxy"] != "http://proxy:8080" {
+		t.Errorf("NewDaemon(false).Proxy = %v, want http_proxy set", daemon.Proxy)
+	}
+
+	daemon = NewDaemon(true)
+	if daemon.Proxy != nil {
+		t.Errorf("NewDaemon(true).Proxy = %v, want nil", daemon.Proxy)
+	}
+}
+
+func TestDaemonWriteConfig(t *testing.T) {
+	dir := t.TempDir()
+	configPath := dir + "/config.json"
+
+	daemon := Daemon{Proxy: map[string]string{
+		"http_proxy": "http://proxy:8080",
+		"HTTP_PROXY": "http://proxy:8080",
+		"no_proxy":   "localhost",
+		"NO_PROXY":   "localhost",
+	}}
+
+	if err := daemon.WriteConfig(configPath); err != nil {
+		t.Fatalf("WriteConfig() error = %v", err)
+	}
+
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatalf("failed to read written config: %v", err)
+	}
+	if !strings.Contains(string(data), `"httpProxy": "http://proxy:8080"`) {
+		t.Errorf("written config %s does not contain expected httpProxy", data)
+	}
+	if !strings.Contains(string(data), `"noProxy": "localhost"`) {
+		t.Errorf("written config %s does not contain expected noProxy", data)
+	}
+}
+
+func TestWriteDockerConfigProxyPreservesAuths(t *testing.T) {
+	dir := t.TempDir()
+	configPath := dir + "/config.json"
+
+	if err := WriteDockerConfig(configPath, map[string]Login{
+		"docker.io/library/alpine": {Username: "user", Password: "pass"},
+	}); err != nil {
+		t.Fatalf("WriteDockerConfig() error = %v", err)
+	}
+
+	if err := WriteDockerConfigProxy(configPath, map[string]string{"http_proxy": "http://proxy:8080"}); err != nil {
+		t.Fatalf("WriteDockerConfigProxy() error = %v", err)
+	}
+
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatalf("failed to read written config: %v", err)
+	}
+	if !strings.Contains(string(data), `"docker.io"`) {
+		t.Errorf("written config %s lost previously written auths", data)
+	}
+	if !strings.Contains(string(data), `"httpProxy": "http://proxy:8080"`) {
+		t.Errorf("written config %s does not contain expected httpProxy", data)
+	}
+}
@@ -0,0 +1,282 @@
+package docker
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Keyring resolves the best matching Login for an image reference out of a
+// set of registry credentials, using glob matching on the registry host and
+// path components of each Login.Registry pattern.
+type Keyring struct {
+	logins []Login
+}
+
+// NewKeyring builds a Keyring from the configured logins, such as the
+// plugin's single Login plus any entries parsed from PLUGIN_LOGINS.
+func NewKeyring(logins []Login) Keyring {
+	return Keyring{logins: logins}
+}
+
+// matchScore ranks how specific a matched credential pattern is, so that
+// the most specific pattern wins when more than one matches a reference.
+type matchScore struct {
+	hostLen       int
+	hostWildcards int
+	pathLen       int
+}
+
+// moreSpecific reports whether s should be preferred over other: the
+// longest host wins, ties broken by fewest host wildcards, ties broken by
+// the longest matched path prefix.
+func (s matchScore) moreSpecific(other matchScore) bool {
+	if s.hostLen != other.hostLen {
+		return s.hostLen > other.hostLen
+	}
+	if s.hostWildcards != other.hostWildcards {
+		return s.hostWildcards < other.hostWildcards
+	}
+	return s.pathLen > other.pathLen
+}
+
+// Match returns the best matching Login for ref, a "registry[/path]"
+// string, or false if no configured Login's Registry pattern matches it.
+func (k Keyring) Match(ref string) (Login, bool) {
+	var (
+		best    Login
+		bestFor matchScore
+		matched bool
+	)
+
+	for _, login := range k.logins {
+		score, ok := scoreMatch(login.Registry, ref)
+		if !ok {
+			continue
+		}
+		if !matched || score.moreSpecific(bestFor) {
+			best, bestFor, matched = login, score, true
+		}
+	}
+
+	return best, matched
+}
+
+// PullLogin returns the first target (in priority order, e.g. mirror
+// before repo) that has a matching credential, for deciding which login
+// to `docker login` with before pulling the build cache.
+func (k Keyring) PullLogin(targets ...string) (Login, bool) {
+	for _, target := range targets {
+		if target == "" {
+			continue
+		}
+		if login, ok := k.Match(stripTagDigest(target)); ok {
+			return login, true
+		}
+	}
+	return Login{}, false
+}
+
+// ResolveTargets matches every target against the keyring and returns the
+// resolved credentials keyed by the target's registry[/path], skipping
+// targets with no matching Login.
+func (k Keyring) ResolveTargets(targets []string) map[string]Login {
+	resolved := make(map[string]Login)
+	for _, target := range targets {
+		ref := stripTagDigest(target)
+		if login, ok := k.Match(ref); ok {
+			resolved[ref] = login
+		}
+	}
+	return resolved
+}
+
+// scoreMatch reports whether pattern matches target, and if so how
+// specific the match is.
+func scoreMatch(pattern, target string) (matchScore, bool) {
+	patternHost, patternPath := splitHostPath(pattern)
+	targetHost, targetPath := splitHostPath(target)
+
+	if !matchHost(patternHost, targetHost) {
+		return matchScore{}, false
+	}
+
+	pathLen, ok := matchPath(patternPath, targetPath)
+	if !ok {
+		return matchScore{}, false
+	}
+
+	return matchScore{
+		hostLen:       len(patternHost),
+		hostWildcards: strings.Count(patternHost, "*"),
+		pathLen:       pathLen,
+	}, true
+}
+
+// matchHost reports whether the host (with optional port) pattern matches
+// target. The pattern's port, if any, must match target's port exactly; a
+// pattern with no port matches any target port. Each DNS label may contain
+// `*` wildcards, which match any run of non-`.` characters within that
+// single label.
+func matchHost(pattern, target string) bool {
+	patternHost, patternPort := splitHostPort(pattern)
+	targetHost, targetPort := splitHostPort(target)
+
+	if patternPort != "" && patternPort != targetPort {
+		return false
+	}
+
+	patternLabels := strings.Split(patternHost, ".")
+	targetLabels := strings.Split(targetHost, ".")
+	if len(patternLabels) != len(targetLabels) {
+		return false
+	}
+
+	for i, label := range patternLabels {
+		ok, err := path.Match(label, targetLabels[i])
+		if err != nil || !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// matchPath reports whether pattern is a glob-matching prefix of target's
+// path components, returning the number of pattern components matched.
+func matchPath(pattern, target string) (int, bool) {
+	if pattern == "" {
+		return 0, true
+	}
+
+	patternParts := strings.Split(pattern, "/")
+	targetParts := strings.Split(target, "/")
+	if len(patternParts) > len(targetParts) {
+		return 0, false
+	}
+
+	for i, part := range patternParts {
+		ok, err := path.Match(part, targetParts[i])
+		if err != nil || !ok {
+			return 0, false
+		}
+	}
+
+	return len(patternParts), true
+}
+
+// splitHostPath splits a "registry[/path]" string into its registry host
+// and path components.
+func splitHostPath(ref string) (host, path string) {
+	if idx := strings.Index(ref, "/"); idx != -1 {
+		return ref[:idx], ref[idx+1:]
+	}
+	return ref, ""
+}
+
+// splitHostPort splits a "host[:port]" string into its host and port.
+func splitHostPort(host string) (string, string) {
+	if idx := strings.LastIndex(host, ":"); idx != -1 {
+		return host[:idx], host[idx+1:]
+	}
+	return host, ""
+}
+
+// stripTagDigest strips a trailing `:tag` and/or `@sha256:...` digest
+// suffix from an image reference, leaving the bare registry[/path].
+func stripTagDigest(image string) string {
+	if idx := strings.Index(image, "@"); idx != -1 {
+		image = image[:idx]
+	}
+
+	lastSlash := strings.LastIndex(image, "/")
+	if lastColon := strings.LastIndex(image, ":"); lastColon > lastSlash {
+		image = image[:lastColon]
+	}
+
+	return image
+}
+
+// dockerConfig mirrors the subset of ~/.docker/config.json that the
+// plugin writes so that `docker push`/`docker pull` pick the right
+// credential per registry, and so pull-time proxy settings are forwarded
+// into containers started from the pulled images.
+type dockerConfig struct {
+	Auths   map[string]dockerConfigAuth `json:"auths"`
+	Proxies *dockerConfigProxies        `json:"proxies,omitempty"`
+}
+
+type dockerConfigAuth struct {
+	Auth string `json:"auth"`
+}
+
+type dockerConfigProxies struct {
+	Default dockerConfigProxy `json:"default"`
+}
+
+type dockerConfigProxy struct {
+	HTTPProxy  string `json:"httpProxy,omitempty"`
+	HTTPSProxy string `json:"httpsProxy,omitempty"`
+	NoProxy    string `json:"noProxy,omitempty"`
+	AllProxy   string `json:"allProxy,omitempty"`
+}
+
+// WriteDockerConfig writes the resolved per-registry credentials to the
+// docker client config file at configPath.
+func WriteDockerConfig(configPath string, logins map[string]Login) error {
+	cfg := dockerConfig{Auths: make(map[string]dockerConfigAuth)}
+
+	for ref, login := range logins {
+		host, _ := splitHostPath(ref)
+		auth := base64.StdEncoding.EncodeToString([]byte(login.Username + ":" + login.Password))
+		cfg.Auths[host] = dockerConfigAuth{Auth: auth}
+	}
+
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(configPath), 0700); err != nil {
+		return err
+	}
+
+	return os.WriteFile(configPath, data, 0600)
+}
+
+// WriteDockerConfigProxy merges proxy (as returned by Proxy()) into the
+// "proxies" section of the docker client config file at configPath,
+// preserving any credentials already written there by WriteDockerConfig,
+// so pull-time proxying reaches containers started from pulled images.
+func WriteDockerConfigProxy(configPath string, proxy map[string]string) error {
+	cfg := dockerConfig{Auths: make(map[string]dockerConfigAuth)}
+
+	if data, err := os.ReadFile(configPath); err == nil {
+		if err := json.Unmarshal(data, &cfg); err != nil {
+			return err
+		}
+	}
+
+	cfg.Proxies = &dockerConfigProxies{
+		Default: dockerConfigProxy{
+			HTTPProxy:  proxy["http_proxy"],
+			HTTPSProxy: proxy["https_proxy"],
+			NoProxy:    proxy["no_proxy"],
+			AllProxy:   proxy["all_proxy"],
+		},
+	}
+
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(configPath), 0700); err != nil {
+		return err
+	}
+
+	return os.WriteFile(configPath, data, 0600)
+}
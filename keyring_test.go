@@ -0,0 +1,163 @@
+package docker
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestKeyringMatch(t *testing.T) {
+	tcs := []struct {
+		name    string
+		logins  []Login
+		ref     string
+		wantReg string
+		wantOK  bool
+	}{
+		{
+			name: "wildcard subdomain match",
+			logins: []Login{
+				{Registry: "*.gcr.io", Username: "gcr-user"},
+			},
+			ref:     "eu.gcr.io/myproj/myimage",
+			wantReg: "*.gcr.io",
+			wantOK:  true,
+		},
+		{
+			name: "exact host preferred over wildcard",
+			logins: []Login{
+				{Registry: "*.example.com", Username: "wildcard-user"},
+				{Registry: "sub.example.com", Username: "exact-user"},
+			},
+			ref:     "sub.example.com/app",
+			wantReg: "sub.example.com",
+			wantOK:  true,
+		},
+		{
+			name: "port-qualified registry",
+			logins: []Login{
+				{Registry: "localhost:5000", Username: "local-user"},
+			},
+			ref:     "localhost:5000/myimage",
+			wantReg: "localhost:5000",
+			wantOK:  true,
+		},
+		{
+			name: "port mismatch does not match",
+			logins: []Login{
+				{Registry: "localhost:5000", Username: "local-user"},
+			},
+			ref:    "localhost:6000/myimage",
+			wantOK: false,
+		},
+		{
+			name: "path-scoped GAR pattern",
+			logins: []Login{
+				{Registry: "*-docker.pkg.dev/myproj/*", Username: "gar-user"},
+			},
+			ref:     "us-docker.pkg.dev/myproj/myrepo/myimage",
+			wantReg: "*-docker.pkg.dev/myproj/*",
+			wantOK:  true,
+		},
+		{
+			name: "ECR host pattern with region wildcard",
+			logins: []Login{
+				{Registry: "123456.dkr.ecr.*.amazonaws.com", Username: "ecr-user"},
+			},
+			ref:     "123456.dkr.ecr.us-east-1.amazonaws.com/myimage",
+			wantReg: "123456.dkr.ecr.*.amazonaws.com",
+			wantOK:  true,
+		},
+		{
+			name: "longest path prefix wins",
+			logins: []Login{
+				{Registry: "*-docker.pkg.dev/myproj", Username: "project-user"},
+				{Registry: "*-docker.pkg.dev/myproj/myrepo", Username: "repo-user"},
+			},
+			ref:     "us-docker.pkg.dev/myproj/myrepo/myimage",
+			wantReg: "*-docker.pkg.dev/myproj/myrepo",
+			wantOK:  true,
+		},
+		{
+			name: "no match",
+			logins: []Login{
+				{Registry: "*.gcr.io", Username: "gcr-user"},
+			},
+			ref:    "docker.io/library/alpine",
+			wantOK: false,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			keyring := NewKeyring(tc.logins)
+			login, ok := keyring.Match(tc.ref)
+			if ok != tc.wantOK {
+				t.Fatalf("Match(%q) ok = %v, want %v", tc.ref, ok, tc.wantOK)
+			}
+			if ok && login.Registry != tc.wantReg {
+				t.Errorf("Match(%q) = %q, want %q", tc.ref, login.Registry, tc.wantReg)
+			}
+		})
+	}
+}
+
+func TestStripTagDigest(t *testing.T) {
+	tcs := []struct {
+		name  string
+		image string
+		want  string
+	}{
+		{"bare name", "alpine", "alpine"},
+		{"tag", "alpine:3.18", "alpine"},
+		{"digest", "alpine@sha256:abcd", "alpine"},
+		{"tag and digest", "alpine:3.18@sha256:abcd", "alpine"},
+		{"port not mistaken for tag", "localhost:5000/alpine", "localhost:5000/alpine"},
+		{"port and tag", "localhost:5000/alpine:3.18", "localhost:5000/alpine"},
+		{"path with tag", "host.example.com/ns/name:tag", "host.example.com/ns/name"},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := stripTagDigest(tc.image); got != tc.want {
+				t.Errorf("stripTagDigest(%q) = %q, want %q", tc.image, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestKeyringPullLogin(t *testing.T) {
+	keyring := NewKeyring([]Login{
+		{Registry: "*.gcr.io", Username: "gcr-user"},
+		{Registry: "docker.io", Username: "hub-user"},
+	})
+
+	login, ok := keyring.PullLogin("", "mirror.gcr.io/library/alpine:latest", "docker.io/library/alpine")
+	if !ok {
+		t.Fatal("PullLogin() ok = false, want true")
+	}
+	if login.Username != "gcr-user" {
+		t.Errorf("PullLogin() username = %q, want %q", login.Username, "gcr-user")
+	}
+}
+
+func TestWriteDockerConfig(t *testing.T) {
+	dir := t.TempDir()
+	configPath := dir + "/config.json"
+
+	logins := map[string]Login{
+		"eu.gcr.io/myproj/myimage": {Username: "gcr-user", Password: "gcr-pass"},
+	}
+
+	if err := WriteDockerConfig(configPath, logins); err != nil {
+		t.Fatalf("WriteDockerConfig() error = %v", err)
+	}
+
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatalf("failed to read written config: %v", err)
+	}
+	if !strings.Contains(string(data), `"eu.gcr.io"`) {
+		t.Errorf("written config %s does not contain expected registry key", data)
+	}
+}
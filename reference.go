@@ -0,0 +1,156 @@
+package docker
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	defaultRegistry  = "docker.io"
+	defaultNamespace = "library"
+)
+
+var (
+	namePattern   = regexp.MustCompile(`^[a-z0-9]+(?:[._-][a-z0-9]+)*$`)
+	tagPattern    = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9._-]{0,127}$`)
+	digestPattern = regexp.MustCompile(`^[a-z0-9]+:[a-fA-F0-9]{32,}$`)
+)
+
+// Reference is a canonical, decomposed image reference: the registry,
+// namespace, and name identify the repository; Tag and Digest, if
+// present, pin it to a specific revision.
+type Reference struct {
+	Registry  string
+	Namespace string
+	Name      string
+	Tag       string
+	Digest    string
+}
+
+// ParseReference decomposes an image string into a Reference, following
+// Docker's reference rules: one, two, or three slash-separated path
+// components (a bare name defaults to docker.io/library; "host[:port]/name"
+// or "host[:port]/ns/name" takes an explicit registry and, for the
+// two-component form, no namespace; otherwise "ns/name" defaults to
+// docker.io). A component is recognized as a registry host by containing a
+// `.` or `:`, or being `localhost`. A trailing `:tag` and/or `@sha256:...`
+// digest suffix is stripped and returned separately. An empty tag is left
+// empty here; it only defaults to "latest" in Reference.String().
+func ParseReference(s string) (Reference, error) {
+	if s == "" {
+		return Reference{}, fmt.Errorf("docker: empty image reference")
+	}
+
+	rest := s
+
+	var digest string
+	if idx := strings.Index(rest, "@"); idx != -1 {
+		digest = rest[idx+1:]
+		rest = rest[:idx]
+		if !digestPattern.MatchString(digest) {
+			return Reference{}, fmt.Errorf("docker: invalid digest %q in reference %q", digest, s)
+		}
+	}
+
+	var tag string
+	lastSlash := strings.LastIndex(rest, "/")
+	if lastColon := strings.LastIndex(rest, ":"); lastColon > lastSlash {
+		tag = rest[lastColon+1:]
+		rest = rest[:lastColon]
+		if !tagPattern.MatchString(tag) {
+			return Reference{}, fmt.Errorf("docker: invalid tag %q in reference %q", tag, s)
+		}
+	}
+
+	if rest == "" {
+		return Reference{}, fmt.Errorf("docker: missing repository name in reference %q", s)
+	}
+
+	parts := strings.Split(rest, "/")
+
+	var ref Reference
+	switch len(parts) {
+	case 1:
+		ref = Reference{Registry: defaultRegistry, Namespace: defaultNamespace, Name: parts[0]}
+	case 2:
+		if looksLikeRegistryHost(parts[0]) {
+			ref = Reference{Registry: parts[0], Name: parts[1]}
+		} else {
+			ref = Reference{Registry: defaultRegistry, Namespace: parts[0], Name: parts[1]}
+		}
+	case 3:
+		if !looksLikeRegistryHost(parts[0]) {
+			return Reference{}, fmt.Errorf("docker: %q is not a valid registry host in reference %q", parts[0], s)
+		}
+		ref = Reference{Registry: parts[0], Namespace: parts[1], Name: parts[2]}
+	default:
+		return Reference{}, fmt.Errorf("docker: too many path components in reference %q", s)
+	}
+
+	if ref.Namespace != "" && !namePattern.MatchString(ref.Namespace) {
+		return Reference{}, fmt.Errorf("docker: invalid namespace %q in reference %q", ref.Namespace, s)
+	}
+	if !namePattern.MatchString(ref.Name) {
+		return Reference{}, fmt.Errorf("docker: invalid name %q in reference %q", ref.Name, s)
+	}
+
+	ref.Tag = tag
+	ref.Digest = digest
+	return ref, nil
+}
+
+// looksLikeRegistryHost reports whether s is shaped like a registry host
+// rather than a namespace: it contains a `.` or `:`, or is "localhost".
+func looksLikeRegistryHost(s string) bool {
+	return strings.ContainsAny(s, ".:") || s == "localhost"
+}
+
+// String returns the canonical "registry/namespace/name:tag[@digest]"
+// form of r, defaulting an empty Tag to "latest". Namespace is omitted
+// when empty, e.g. for a registry/name reference with no namespace.
+func (r Reference) String() string {
+	tag := r.Tag
+	if tag == "" {
+		tag = "latest"
+	}
+
+	name := r.Name
+	if r.Namespace != "" {
+		name = r.Namespace + "/" + r.Name
+	}
+
+	ref := fmt.Sprintf("%s/%s:%s", r.Registry, name, tag)
+	if r.Digest != "" {
+		ref += "@" + r.Digest
+	}
+	return ref
+}
+
+// Exact returns just the "name:tag[@digest]" portion of r, defaulting an
+// empty Tag to "latest".
+func (r Reference) Exact() string {
+	tag := r.Tag
+	if tag == "" {
+		tag = "latest"
+	}
+
+	exact := r.Name + ":" + tag
+	if r.Digest != "" {
+		exact += "@" + r.Digest
+	}
+	return exact
+}
+
+// WithRegistry returns a copy of r with its Registry replaced, e.g. to
+// rewrite a reference to pull through a mirror.
+func (r Reference) WithRegistry(registry string) Reference {
+	r.Registry = registry
+	return r
+}
+
+// WithTag returns a copy of r with its Tag replaced.
+func (r Reference) WithTag(tag string) Reference {
+	r.Tag = tag
+	return r
+}
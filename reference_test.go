@@ -0,0 +1,168 @@
+package docker
+
+import "testing"
+
+func TestParseReference(t *testing.T) {
+	tcs := []struct {
+		name    string
+		ref     string
+		want    Reference
+		wantErr bool
+	}{
+		{
+			name: "bare name",
+			ref:  "foo",
+			want: Reference{Registry: "docker.io", Namespace: "library", Name: "foo"},
+		},
+		{
+			name: "bare name with tag",
+			ref:  "foo:tag",
+			want: Reference{Registry: "docker.io", Namespace: "library", Name: "foo", Tag: "tag"},
+		},
+		{
+			name: "bare name with digest",
+			ref:  "foo@sha256:" + sha256Hex,
+			want: Reference{Registry: "docker.io", Namespace: "library", Name: "foo", Digest: "sha256:" + sha256Hex},
+		},
+		{
+			name: "namespace and name",
+			ref:  "ns/foo",
+			want: Reference{Registry: "docker.io", Namespace: "ns", Name: "foo"},
+		},
+		{
+			name: "two-part dotted host with no namespace",
+			ref:  "123456.dkr.ecr.us-east-1.amazonaws.com/myimage",
+			want: Reference{Registry: "123456.dkr.ecr.us-east-1.amazonaws.com", Name: "myimage"},
+		},
+		{
+			name: "two-part port-qualified host with no namespace",
+			ref:  "localhost:5000/foo",
+			want: Reference{Registry: "localhost:5000", Name: "foo"},
+		},
+		{
+			name: "explicit host, port, tag and digest",
+			ref:  "host:5000/ns/foo:tag@sha256:" + sha256Hex,
+			want: Reference{Registry: "host:5000", Namespace: "ns", Name: "foo", Tag: "tag", Digest: "sha256:" + sha256Hex},
+		},
+		{
+			name: "dotted host",
+			ref:  "registry.example.com/ns/foo",
+			want: Reference{Registry: "registry.example.com", Namespace: "ns", Name: "foo"},
+		},
+		{
+			name: "localhost is a registry host",
+			ref:  "localhost/ns/foo",
+			want: Reference{Registry: "localhost", Namespace: "ns", Name: "foo"},
+		},
+		{
+			name:    "invalid uppercase name",
+			ref:     "Foo",
+			wantErr: true,
+		},
+		{
+			name:    "invalid uppercase namespace",
+			ref:     "NS/foo",
+			wantErr: true,
+		},
+		{
+			name:    "too many path components",
+			ref:     "a/b/c/d",
+			wantErr: true,
+		},
+		{
+			name:    "ambiguous three-part without host",
+			ref:     "ns/sub/foo",
+			wantErr: true,
+		},
+		{
+			name:    "empty reference",
+			ref:     "",
+			wantErr: true,
+		},
+		{
+			name:    "invalid digest",
+			ref:     "foo@not-a-digest",
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ParseReference(tc.ref)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("ParseReference(%q) = %+v, want error", tc.ref, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseReference(%q) unexpected error: %v", tc.ref, err)
+			}
+			if got != tc.want {
+				t.Errorf("ParseReference(%q) = %+v, want %+v", tc.ref, got, tc.want)
+			}
+		})
+	}
+}
+
+const sha256Hex = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+func TestReferenceString(t *testing.T) {
+	ref := Reference{Registry: "docker.io", Namespace: "library", Name: "foo"}
+	if got, want := ref.String(), "docker.io/library/foo:latest"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+
+	ref.Tag = "v1"
+	if got, want := ref.String(), "docker.io/library/foo:v1"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+
+	ref.Digest = "sha256:" + sha256Hex
+	if got, want := ref.String(), "docker.io/library/foo:v1@sha256:"+sha256Hex; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
+
+func TestReferenceStringNoNamespace(t *testing.T) {
+	ref := Reference{Registry: "localhost:5000", Name: "foo"}
+	if got, want := ref.String(), "localhost:5000/foo:latest"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
+
+func TestReferenceExact(t *testing.T) {
+	ref := Reference{Registry: "docker.io", Namespace: "library", Name: "foo"}
+	if got, want := ref.Exact(), "foo:latest"; got != want {
+		t.Errorf("Exact() = %q, want %q", got, want)
+	}
+
+	ref.Tag = "v1"
+	ref.Digest = "sha256:" + sha256Hex
+	if got, want := ref.Exact(), "foo:v1@sha256:"+sha256Hex; got != want {
+		t.Errorf("Exact() = %q, want %q", got, want)
+	}
+}
+
+func TestReferenceWithers(t *testing.T) {
+	ref, err := ParseReference("ns/foo")
+	if err != nil {
+		t.Fatalf("ParseReference() error = %v", err)
+	}
+
+	mirrored := ref.WithRegistry("mirror.example.com")
+	if mirrored.Registry != "mirror.example.com" {
+		t.Errorf("WithRegistry() = %q, want %q", mirrored.Registry, "mirror.example.com")
+	}
+	if ref.Registry == "mirror.example.com" {
+		t.Errorf("WithRegistry() mutated the original reference")
+	}
+
+	tagged := ref.WithTag("v2")
+	if tagged.Tag != "v2" {
+		t.Errorf("WithTag() = %q, want %q", tagged.Tag, "v2")
+	}
+	if ref.Tag == "v2" {
+		t.Errorf("WithTag() mutated the original reference")
+	}
+}